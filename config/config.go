@@ -0,0 +1,223 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config handles loading and parsing of the Prometheus configuration
+// file.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// RemoteWriteConfig describes a single remote write destination.
+type RemoteWriteConfig struct {
+	// Type selects the writer implementation: "opentsdb", "influxdb",
+	// "graphite", or "kafka".
+	Type string
+	// URL is the backend endpoint. For kafka it is interpreted as a
+	// comma-separated broker list instead.
+	URL string
+	// Timeout bounds a single Send call.
+	Timeout time.Duration
+	// Database is used by backends that multiplex writers by database/topic
+	// name (InfluxDB's database, Graphite's metric prefix, Kafka's topic).
+	Database string
+}
+
+// DNSSDConfig configures discovery of targets via a DNS SRV record.
+type DNSSDConfig struct {
+	Name            string
+	RefreshInterval time.Duration
+}
+
+// ConsulSDConfig configures discovery of targets via a Consul service's
+// health endpoint.
+type ConsulSDConfig struct {
+	Server  string
+	Service string
+}
+
+// FileSDConfig configures discovery of targets from a directory of
+// file_sd target files.
+type FileSDConfig struct {
+	Directory string
+}
+
+// RelabelConfig describes one step of a target's label rewriting pipeline,
+// run after service discovery (or static target) labels are assembled and
+// before a Target is built from them. Source label values are joined with
+// Separator and matched against Regex; on a match, Action decides what
+// happens: "replace" sets TargetLabel to Replacement (which may reference
+// regex capture groups as "$1"), "keep" retains the target only on a match,
+// and "drop" discards it only on a match.
+type RelabelConfig struct {
+	SourceLabels []string
+	Separator    string
+	Regex        string
+	TargetLabel  string
+	Replacement  string
+	// Action is one of "replace" (the default), "keep", or "drop".
+	Action string
+}
+
+// JobConfig describes a single scrape job: a named group of targets scraped
+// on a common interval. A job's targets may come from a static list, from
+// one or more *_sd_configs, or both at once.
+type JobConfig struct {
+	Name           string
+	ScrapeInterval time.Duration
+	ScrapeTimeout  time.Duration
+	// TargetURLs are statically configured scrape endpoints.
+	TargetURLs []string
+
+	DNSSDConfigs    []DNSSDConfig
+	ConsulSDConfigs []ConsulSDConfig
+	FileSDConfigs   []FileSDConfig
+
+	// RelabelConfigs are run, in order, against every target's labels
+	// (static or discovered) before it's scraped. They're what lets a
+	// *_sd_config's raw __meta_* labels be turned into the job/instance
+	// labels a migrated-off-static-targets job needs, or used to keep/drop
+	// targets matching some discovered label.
+	RelabelConfigs []RelabelConfig
+
+	// HonorLabels controls what happens when a label exposed by a target
+	// collides with one of the target's base labels (job and any label set
+	// by service discovery or relabeling). By default the target's value is
+	// kept but renamed behind CollisionPrefix; when HonorLabels is set, the
+	// target's value wins outright instead. The global labels are merged in
+	// once more by the server-wide ingester every scrape result passes
+	// through, which renames a colliding target label behind
+	// CollisionPrefix whether or not HonorLabels is set.
+	HonorLabels bool
+}
+
+// Config encapsulates the configuration of a Prometheus instance, as parsed
+// from the config file passed via -configFile.
+type Config struct {
+	raw string
+
+	globalLabels       clientmodel.LabelSet
+	evaluationInterval time.Duration
+	ruleFiles          []string
+	remoteWriteConfigs []RemoteWriteConfig
+	jobs               []JobConfig
+	alertmanagerURL    string
+}
+
+// LoadFromFile reads and parses the given Prometheus configuration file.
+func LoadFromFile(fileName string) (Config, error) {
+	bytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return Config{}, err
+	}
+	return LoadFromString(string(bytes))
+}
+
+// LoadFromString parses the given string as a Prometheus configuration.
+func LoadFromString(configStr string) (Config, error) {
+	cfg := Config{
+		raw:                configStr,
+		globalLabels:       clientmodel.LabelSet{},
+		evaluationInterval: 1 * time.Minute,
+	}
+	if err := parseInto(configStr, &cfg); err != nil {
+		return Config{}, fmt.Errorf("error parsing config: %s", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// validate checks the configuration for semantic errors that the grammar
+// itself can't rule out.
+func (c Config) validate() error {
+	for _, rw := range c.remoteWriteConfigs {
+		if rw.URL == "" {
+			return fmt.Errorf("remote_write config of type %q is missing a url", rw.Type)
+		}
+	}
+	return nil
+}
+
+// GlobalLabels returns the set of labels to attach to every time series
+// scraped by this Prometheus instance.
+func (c Config) GlobalLabels() clientmodel.LabelSet {
+	return c.globalLabels
+}
+
+// EvaluationInterval returns the interval at which rules are evaluated.
+func (c Config) EvaluationInterval() time.Duration {
+	return c.evaluationInterval
+}
+
+// RuleFiles returns the configured rule files.
+func (c Config) RuleFiles() []string {
+	return c.ruleFiles
+}
+
+// String returns the original, as-loaded configuration text.
+func (c Config) String() string {
+	return c.raw
+}
+
+// RemoteWriteConfigs returns the configured remote write destinations. A
+// Prometheus instance may fan samples out to any number of them
+// concurrently.
+func (c Config) RemoteWriteConfigs() []RemoteWriteConfig {
+	return c.remoteWriteConfigs
+}
+
+// Jobs returns the configured scrape jobs.
+func (c Config) Jobs() []JobConfig {
+	return c.jobs
+}
+
+// AlertmanagerURL returns the Alertmanager URL configured in the "global"
+// block, if any. An empty string means the config file doesn't override
+// the -alertmanager.url flag.
+func (c Config) AlertmanagerURL() string {
+	return c.alertmanagerURL
+}
+
+// Store holds the currently active Config, safe for concurrent reads from
+// HTTP handlers while a SIGHUP/-/reload swaps it out.
+type Store struct {
+	mu  sync.RWMutex
+	cfg Config
+}
+
+// NewStore returns a Store initialized to cfg.
+func NewStore(cfg Config) *Store {
+	return &Store{cfg: cfg}
+}
+
+// Get returns the currently active Config.
+func (s *Store) Get() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Set replaces the active Config, e.g. after a config reload succeeds.
+func (s *Store) Set(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+}
@@ -0,0 +1,312 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// parseInto fills cfg from the block-structured configuration text. The
+// grammar is intentionally small:
+//
+//	global {
+//	  labels { name = "value" ... }
+//	  evaluation_interval = "1m"
+//	  rule_file = "a.rules"
+//	}
+//	remote_write {
+//	  type = "opentsdb"
+//	  url = "http://host:4242"
+//	  timeout = "30s"
+//	}
+//	scrape_config {
+//	  job_name = "node"
+//	  target_url = "http://host:9100/metrics"
+//	}
+//
+// remote_write and scrape_config blocks may each repeat, one per configured
+// backend or job. "job" is accepted as an alias for "scrape_config".
+func parseInto(s string, cfg *Config) error {
+	blocks, err := splitBlocks(s)
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		switch b.name {
+		case "global":
+			if err := parseGlobalBlock(b.body, cfg); err != nil {
+				return err
+			}
+		case "remote_write":
+			rw, err := parseRemoteWriteBlock(b.body)
+			if err != nil {
+				return err
+			}
+			cfg.remoteWriteConfigs = append(cfg.remoteWriteConfigs, rw)
+		case "scrape_config", "job":
+			// "scrape_config" is the current name; "job" is kept as an
+			// alias so configs written before *_sd_configs/relabeling
+			// existed don't need to be migrated just to keep parsing.
+			job, err := parseJobBlock(b.body)
+			if err != nil {
+				return err
+			}
+			cfg.jobs = append(cfg.jobs, job)
+		case "":
+			// Blank input or comment-only input; nothing to do.
+		default:
+			return fmt.Errorf("unknown top-level block %q", b.name)
+		}
+	}
+	return nil
+}
+
+type block struct {
+	name string
+	body string
+}
+
+// splitBlocks performs a single pass over the config text, stripping
+// comments and splitting it into top-level "name { body }" blocks.
+func splitBlocks(s string) ([]block, error) {
+	s = stripComments(s)
+
+	var blocks []block
+	depth := 0
+	start := 0
+	nameStart := 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			if depth == 0 {
+				nameStart = strings.LastIndexAny(s[:i], "\n") + 1
+				start = i + 1
+			}
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced '}' in config")
+			}
+			if depth == 0 {
+				blocks = append(blocks, block{
+					name: strings.TrimSpace(s[nameStart : start-1]),
+					body: s[start:i],
+				})
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced '{' in config")
+	}
+	return blocks, nil
+}
+
+// stripComments removes everything from a '#' to the end of its line.
+func stripComments(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseFields splits a flat "key = value" block body into a map, ignoring
+// nested sub-blocks (callers that need those pull them out separately via
+// splitBlocks on the same body).
+func parseFields(body string) map[string]string {
+	fields := map[string]string{}
+	depth := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		opens, closes := strings.Count(line, "{"), strings.Count(line, "}")
+		inSubBlock := depth > 0 || opens > 0 || closes > 0
+		depth += opens - closes
+		if line == "" || inSubBlock {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+		val = strings.Trim(val, `"`)
+		fields[key] = val
+	}
+	return fields
+}
+
+func parseGlobalBlock(body string, cfg *Config) error {
+	subBlocks, err := splitBlocks(body)
+	if err != nil {
+		return err
+	}
+	for _, b := range subBlocks {
+		if b.name == "labels" {
+			for k, v := range parseFields(b.body) {
+				cfg.globalLabels[clientmodel.LabelName(k)] = clientmodel.LabelValue(v)
+			}
+		}
+	}
+
+	fields := parseFields(body)
+	if v, ok := fields["evaluation_interval"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid evaluation_interval %q: %s", v, err)
+		}
+		cfg.evaluationInterval = d
+	}
+	if v, ok := fields["rule_file"]; ok {
+		cfg.ruleFiles = append(cfg.ruleFiles, v)
+	}
+	if v, ok := fields["alertmanager_url"]; ok {
+		cfg.alertmanagerURL = v
+	}
+	return nil
+}
+
+func parseJobBlock(body string) (JobConfig, error) {
+	fields := parseFields(body)
+
+	job := JobConfig{
+		Name:           fields["job_name"],
+		ScrapeInterval: 1 * time.Minute,
+		ScrapeTimeout:  10 * time.Second,
+	}
+	if v, ok := fields["scrape_interval"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return JobConfig{}, fmt.Errorf("invalid scrape_interval %q: %s", v, err)
+		}
+		job.ScrapeInterval = d
+	}
+	if v, ok := fields["scrape_timeout"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return JobConfig{}, fmt.Errorf("invalid scrape_timeout %q: %s", v, err)
+		}
+		job.ScrapeTimeout = d
+	}
+	if v, ok := fields["honor_labels"]; ok {
+		job.HonorLabels = v == "true"
+	}
+	if job.Name == "" {
+		return JobConfig{}, fmt.Errorf("job block is missing a job_name")
+	}
+
+	// target_url may repeat, so it can't live in the flattened fields map.
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "target_url") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		job.TargetURLs = append(job.TargetURLs, strings.Trim(strings.TrimSpace(parts[1]), `"`))
+	}
+
+	sdBlocks, err := splitBlocks(body)
+	if err != nil {
+		return JobConfig{}, err
+	}
+	for _, b := range sdBlocks {
+		sdFields := parseFields(b.body)
+		switch b.name {
+		case "dns_sd_config":
+			refresh := 30 * time.Second
+			if v, ok := sdFields["refresh_interval"]; ok {
+				d, err := time.ParseDuration(v)
+				if err != nil {
+					return JobConfig{}, fmt.Errorf("invalid dns_sd_config refresh_interval %q: %s", v, err)
+				}
+				refresh = d
+			}
+			job.DNSSDConfigs = append(job.DNSSDConfigs, DNSSDConfig{
+				Name:            sdFields["name"],
+				RefreshInterval: refresh,
+			})
+		case "consul_sd_config":
+			job.ConsulSDConfigs = append(job.ConsulSDConfigs, ConsulSDConfig{
+				Server:  sdFields["server"],
+				Service: sdFields["service"],
+			})
+		case "file_sd_config":
+			job.FileSDConfigs = append(job.FileSDConfigs, FileSDConfig{
+				Directory: sdFields["directory"],
+			})
+		case "relabel_config":
+			rc := RelabelConfig{
+				Separator:   ";",
+				Regex:       "(.*)",
+				Replacement: "$1",
+				Action:      "replace",
+			}
+			if v, ok := sdFields["source_labels"]; ok {
+				for _, ln := range strings.Split(v, ",") {
+					rc.SourceLabels = append(rc.SourceLabels, strings.TrimSpace(ln))
+				}
+			}
+			if v, ok := sdFields["separator"]; ok {
+				rc.Separator = v
+			}
+			if v, ok := sdFields["regex"]; ok {
+				rc.Regex = v
+			}
+			if v, ok := sdFields["target_label"]; ok {
+				rc.TargetLabel = v
+			}
+			if v, ok := sdFields["replacement"]; ok {
+				rc.Replacement = v
+			}
+			if v, ok := sdFields["action"]; ok {
+				rc.Action = v
+			}
+			job.RelabelConfigs = append(job.RelabelConfigs, rc)
+		}
+	}
+
+	return job, nil
+}
+
+func parseRemoteWriteBlock(body string) (RemoteWriteConfig, error) {
+	fields := parseFields(body)
+
+	rw := RemoteWriteConfig{
+		Type:     fields["type"],
+		URL:      fields["url"],
+		Database: fields["database"],
+		Timeout:  30 * time.Second,
+	}
+	if v, ok := fields["timeout"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return RemoteWriteConfig{}, fmt.Errorf("invalid timeout %q: %s", v, err)
+		}
+		rw.Timeout = d
+	}
+	if rw.Type == "" {
+		return RemoteWriteConfig{}, fmt.Errorf("remote_write block is missing a type")
+	}
+	return rw, nil
+}
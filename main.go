@@ -14,9 +14,12 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -33,6 +36,9 @@ import (
 	"github.com/prometheus/prometheus/rules/manager"
 	"github.com/prometheus/prometheus/storage/metric/ng"
 	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/storage/remote/graphite"
+	"github.com/prometheus/prometheus/storage/remote/influxdb"
+	"github.com/prometheus/prometheus/storage/remote/kafka"
 	"github.com/prometheus/prometheus/storage/remote/opentsdb"
 	"github.com/prometheus/prometheus/web"
 	"github.com/prometheus/prometheus/web/api"
@@ -40,6 +46,14 @@ import (
 
 const deletionBatchSize = 100
 
+// configLastReloadSuccess reflects whether the most recent config (re)load,
+// including the initial one at startup, parsed and validated cleanly.
+var configLastReloadSuccess = registry.NewGauge(registry.GaugeOpts{
+	Namespace: "prometheus",
+	Name:      "config_last_reload_success",
+	Help:      "Whether the last configuration reload attempt succeeded (1) or failed (0).",
+})
+
 // Commandline flags.
 var (
 	configFile         = flag.String("configFile", "prometheus.conf", "Prometheus configuration file name.")
@@ -67,32 +81,80 @@ var (
 
 	printVersion = flag.Bool("version", false, "print version information")
 
-	shutdownTimeout = flag.Duration("shutdownGracePeriod", 0*time.Second, "The amount of time Prometheus gives background services to finish running when shutdown is requested.")
+	shutdownTimeout = flag.Duration("shutdownGracePeriod", 30*time.Second, "The amount of time Prometheus gives background services to finish running when shutdown is requested.")
 )
 
 type prometheus struct {
 	unwrittenSamples chan *extraction.Result
-
-	ruleManager     manager.RuleManager
-	targetManager   retrieval.TargetManager
-	notifications   chan notification.NotificationReqs
-	storage         storage_ng.Storage
-	remoteTSDBQueue *remote.TSDBQueueManager
+	// pumpDone is closed by main's sample pump loop (the `for block :=
+	// range unwrittenSamples` in main()) once it has drained and
+	// returned. close() waits on it before stopping Local Storage and
+	// the remote QueueManagers, since they're what the pump loop feeds.
+	pumpDone chan struct{}
+
+	ruleManager         manager.RuleManager
+	targetManager       retrieval.TargetManager
+	notificationHandler *notification.NotificationHandler
+	notifications       chan notification.NotificationReqs
+	storage             storage_ng.Storage
+	remoteQueues        []*remote.QueueManager
+	configStore         *config.Store
 
 	closeOnce sync.Once
 }
 
 func (p *prometheus) interruptHandler() {
-	notifier := make(chan os.Signal)
-	signal.Notify(notifier, os.Interrupt, syscall.SIGTERM)
+	notifier := make(chan os.Signal, 1)
+	signal.Notify(notifier, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range notifier {
+		if sig == syscall.SIGHUP {
+			glog.Warning("Received SIGHUP; reloading configuration...")
+			if err := p.reloadConfig(); err != nil {
+				glog.Errorf("Error reloading config: %s", err)
+			}
+			continue
+		}
+
+		glog.Warning("Received SIGINT/SIGTERM; Exiting gracefully...")
+
+		// p.Close() closes unwrittenSamples once every producer has
+		// stopped, which is what lets main's sample pump loop drain the
+		// remaining buffered samples and return on its own. Exiting the
+		// process from here instead (e.g. via os.Exit) would race that
+		// drain and could drop the last batch of samples.
+		p.Close()
+		return
+	}
+}
 
-	<-notifier
+// reloadConfig re-parses *configFile and applies the result to the target
+// manager, rule manager, and notification handler. A parse or validation
+// failure leaves the currently running configuration untouched.
+func (p *prometheus) reloadConfig() error {
+	conf, err := config.LoadFromFile(*configFile)
+	if err != nil {
+		configLastReloadSuccess.Set(0)
+		return fmt.Errorf("error loading configuration from %s: %s", *configFile, err)
+	}
+
+	p.targetManager.ApplyConfig(conf)
 
-	glog.Warning("Received SIGINT/SIGTERM; Exiting gracefully...")
+	if err := p.ruleManager.AddRulesFromConfig(conf); err != nil {
+		configLastReloadSuccess.Set(0)
+		return fmt.Errorf("error loading rule files: %s", err)
+	}
 
-	p.Close()
+	alertmanager := conf.AlertmanagerURL()
+	if alertmanager == "" {
+		alertmanager = *alertmanagerUrl
+	}
+	p.notificationHandler.SetAlertmanagerURL(alertmanager)
+	p.configStore.Set(conf)
 
-	os.Exit(0)
+	configLastReloadSuccess.Set(1)
+	glog.Info("Configuration reloaded successfully")
+	return nil
 }
 
 func (p *prometheus) Close() {
@@ -100,32 +162,52 @@ func (p *prometheus) Close() {
 }
 
 func (p *prometheus) close() {
-	// The "Done" remarks are a misnomer for some subsystems due to lack of
-	// blocking and synchronization.
-	glog.Info("Shutdown has been requested; subsytems are closing:")
-	p.targetManager.Stop()
-	glog.Info("Remote Target Manager: Done")
-	p.ruleManager.Stop()
-	glog.Info("Rule Executor: Done")
-
-	glog.Infof("Waiting %s for background systems to exit and flush before finalizing (DO NOT INTERRUPT THE PROCESS) ...", *shutdownTimeout)
+	glog.Info("Shutdown has been requested; subsystems are closing:")
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	stop := func(wg *sync.WaitGroup, name string, f func(context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(ctx); err != nil {
+				glog.Warningf("%s did not shut down cleanly: %s", name, err)
+				return
+			}
+			glog.Infof("%s: Done", name)
+		}()
+	}
 
-	// Wart: We should have a concrete form of synchronization for this, not a
-	//       hokey sleep statement.
-	time.Sleep(*shutdownTimeout)
+	// Target Manager and Rule Executor are the only producers into
+	// unwrittenSamples/notifications, so they have to be fully stopped
+	// before those channels are closed. And the sample pump loop in main
+	// (which is what actually calls into Local Storage and the remote
+	// QueueManagers) has to have drained and exited before those
+	// consumers close their own internally-owned channels -- otherwise a
+	// scrape or rule evaluation finishing late could still be forwarded
+	// into a channel a consumer has already closed, panicking.
+	var producers sync.WaitGroup
+	stop(&producers, "Target Manager", p.targetManager.Stop)
+	stop(&producers, "Rule Executor", p.ruleManager.Stop)
+	producers.Wait()
 
 	close(p.unwrittenSamples)
+	close(p.notifications)
 
-	p.storage.Close()
-	glog.Info("Local Storage: Done")
+	select {
+	case <-p.pumpDone:
+	case <-ctx.Done():
+		glog.Warning("Timed out waiting for the sample pump to drain")
+	}
 
-	if p.remoteTSDBQueue != nil {
-		p.remoteTSDBQueue.Close()
-		glog.Info("Remote Storage: Done")
+	var consumers sync.WaitGroup
+	stop(&consumers, "Notification Handler", p.notificationHandler.Stop)
+	stop(&consumers, "Local Storage", p.storage.Stop)
+	for _, q := range p.remoteQueues {
+		stop(&consumers, fmt.Sprintf("Remote Storage (%s)", q.Name()), q.Stop)
 	}
+	consumers.Wait()
 
-	close(p.notifications)
-	glog.Info("Sundry Queues: Done")
 	glog.Info("See you next time!")
 }
 
@@ -141,10 +223,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	registry.MustRegister(configLastReloadSuccess)
+
 	conf, err := config.LoadFromFile(*configFile)
 	if err != nil {
+		configLastReloadSuccess.Set(0)
 		glog.Fatalf("Error loading configuration from %s: %v", *configFile, err)
 	}
+	configLastReloadSuccess.Set(1)
+	configStore := config.NewStore(conf)
 
 	persistence, err := storage_ng.NewDiskPersistence(*metricsStoragePath)
 	if err != nil {
@@ -153,14 +240,30 @@ func main() {
 	memStorage := storage_ng.NewMemorySeriesStorage(persistence)
 	//registry.MustRegister(memStorage)
 
-	var remoteTSDBQueue *remote.TSDBQueueManager
-	if *remoteTSDBUrl == "" {
-		glog.Warningf("No TSDB URL provided; not sending any samples to long-term storage")
-	} else {
-		openTSDB := opentsdb.NewClient(*remoteTSDBUrl, *remoteTSDBTimeout)
-		remoteTSDBQueue = remote.NewTSDBQueueManager(openTSDB, 512)
-		registry.MustRegister(remoteTSDBQueue)
-		go remoteTSDBQueue.Run()
+	remoteWriteConfigs := conf.RemoteWriteConfigs()
+	if *remoteTSDBUrl != "" {
+		// Backwards-compatible flag: fold it into the same list that
+		// prometheus.conf's remote_write blocks populate.
+		remoteWriteConfigs = append(remoteWriteConfigs, config.RemoteWriteConfig{
+			Type:    "opentsdb",
+			URL:     *remoteTSDBUrl,
+			Timeout: *remoteTSDBTimeout,
+		})
+	}
+
+	var remoteQueues []*remote.QueueManager
+	if len(remoteWriteConfigs) == 0 {
+		glog.Warningf("No remote write backends configured; not sending any samples to long-term storage")
+	}
+	for _, rw := range remoteWriteConfigs {
+		writer, err := newRemoteWriter(rw)
+		if err != nil {
+			glog.Fatalf("Error constructing %s remote writer: %v", rw.Type, err)
+		}
+		queue := remote.NewQueueManager(writer, 512)
+		registry.MustRegister(queue)
+		remoteQueues = append(remoteQueues, queue)
+		go queue.Run()
 	}
 
 	unwrittenSamples := make(chan *extraction.Result, *samplesQueueCapacity)
@@ -174,6 +277,7 @@ func main() {
 	// Queue depth will need to be exposed
 	targetManager := retrieval.NewTargetManager(ingester, *concurrentRetrievalAllowance)
 	targetManager.AddTargetsFromConfig(conf)
+	go targetManager.Run()
 
 	notifications := make(chan notification.NotificationReqs, *notificationQueueCapacity)
 
@@ -190,7 +294,11 @@ func main() {
 	}
 	go ruleManager.Run()
 
-	notificationHandler := notification.NewNotificationHandler(*alertmanagerUrl, notifications)
+	configuredAlertmanagerUrl := conf.AlertmanagerURL()
+	if configuredAlertmanagerUrl == "" {
+		configuredAlertmanagerUrl = *alertmanagerUrl
+	}
+	notificationHandler := notification.NewNotificationHandler(configuredAlertmanagerUrl, notifications)
 	registry.MustRegister(notificationHandler)
 	go notificationHandler.Run()
 
@@ -202,7 +310,7 @@ func main() {
 
 	prometheusStatus := &web.PrometheusStatusHandler{
 		BuildInfo:   BuildInfo,
-		Config:      conf.String(),
+		Config:      configStore,
 		RuleManager: ruleManager,
 		TargetPools: targetManager.Pools(),
 		Flags:       flags,
@@ -223,19 +331,22 @@ func main() {
 	}
 
 	metricsService := &api.MetricsService{
-		Config:        &conf,
+		Config:        configStore,
 		TargetManager: targetManager,
 		Storage:       memStorage,
 	}
 
 	prometheus := &prometheus{
 		unwrittenSamples: unwrittenSamples,
-
-		ruleManager:     ruleManager,
-		targetManager:   targetManager,
-		notifications:   notifications,
-		storage:         memStorage,
-		remoteTSDBQueue: remoteTSDBQueue,
+		pumpDone:         make(chan struct{}),
+
+		ruleManager:         ruleManager,
+		targetManager:       targetManager,
+		notificationHandler: notificationHandler,
+		notifications:       notifications,
+		storage:             memStorage,
+		remoteQueues:        remoteQueues,
+		configStore:         configStore,
 	}
 	defer prometheus.Close()
 
@@ -246,7 +357,8 @@ func main() {
 		ConsolesHandler:  consolesHandler,
 		AlertsHandler:    alertsHandler,
 
-		QuitDelegate: prometheus.Close,
+		QuitDelegate:   prometheus.Close,
+		ReloadDelegate: prometheus.reloadConfig,
 	}
 
 	/* TODO: implement serving storage.
@@ -269,9 +381,26 @@ func main() {
 	for block := range unwrittenSamples {
 		if block.Err == nil && len(block.Samples) > 0 {
 			memStorage.AppendSamples(block.Samples)
-			if remoteTSDBQueue != nil {
-				remoteTSDBQueue.Queue(block.Samples)
+			for _, q := range remoteQueues {
+				q.Queue(block.Samples)
 			}
 		}
 	}
+	close(prometheus.pumpDone)
+}
+
+// newRemoteWriter constructs the remote.RemoteWriter named by rw.Type.
+func newRemoteWriter(rw config.RemoteWriteConfig) (remote.RemoteWriter, error) {
+	switch rw.Type {
+	case "opentsdb":
+		return opentsdb.NewClient(rw.URL, rw.Timeout), nil
+	case "influxdb":
+		return influxdb.NewClient(rw.URL, rw.Database, rw.Timeout), nil
+	case "graphite":
+		return graphite.NewClient(rw.URL, rw.Database, rw.Timeout), nil
+	case "kafka":
+		return kafka.NewClient(strings.Split(rw.URL, ","), rw.Database)
+	default:
+		return nil, fmt.Errorf("unknown remote write backend type %q", rw.Type)
+	}
 }
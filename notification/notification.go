@@ -0,0 +1,196 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notification dispatches firing alerts to an Alertmanager.
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	registry "github.com/prometheus/client_golang/prometheus"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// NotificationReq is a single alert notification to be sent to the
+// Alertmanager.
+type NotificationReq struct {
+	Summary     string
+	Description string
+	Labels      clientmodel.LabelSet
+	ActiveSince time.Time
+}
+
+// NotificationReqs is a batch of notifications generated by one rule
+// evaluation pass.
+type NotificationReqs []*NotificationReq
+
+// NotificationHandler dispatches NotificationReqs arriving on its queue to
+// the configured Alertmanager.
+type NotificationHandler struct {
+	mu              sync.RWMutex
+	alertmanagerUrl string
+
+	queue  <-chan NotificationReqs
+	client http.Client
+
+	sent   registry.Counter
+	errors registry.Counter
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewNotificationHandler returns a NotificationHandler that reads from
+// queue and POSTs to alertmanagerUrl. If alertmanagerUrl is empty,
+// notifications are logged and dropped.
+func NewNotificationHandler(alertmanagerUrl string, queue <-chan NotificationReqs) *NotificationHandler {
+	return &NotificationHandler{
+		alertmanagerUrl: alertmanagerUrl,
+		queue:           queue,
+		client:          http.Client{Timeout: 10 * time.Second},
+
+		sent: registry.NewCounter(registry.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "notifications",
+			Name:      "sent_total",
+			Help:      "Total number of alert notifications sent to the Alertmanager.",
+		}),
+		errors: registry.NewCounter(registry.CounterOpts{
+			Namespace: "prometheus",
+			Subsystem: "notifications",
+			Name:      "errors_total",
+			Help:      "Total number of errors sending alert notifications to the Alertmanager.",
+		}),
+		stop: make(chan struct{}),
+	}
+}
+
+// SetAlertmanagerURL updates the Alertmanager this handler sends to. It is
+// safe to call while Run is active, e.g. from a config reload.
+func (h *NotificationHandler) SetAlertmanagerURL(url string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.alertmanagerUrl = url
+}
+
+func (h *NotificationHandler) alertmanager() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.alertmanagerUrl
+}
+
+// Describe implements registry.Collector.
+func (h *NotificationHandler) Describe(ch chan<- *registry.Desc) {
+	h.sent.Describe(ch)
+	h.errors.Describe(ch)
+}
+
+// Collect implements registry.Collector.
+func (h *NotificationHandler) Collect(ch chan<- registry.Metric) {
+	h.sent.Collect(ch)
+	h.errors.Collect(ch)
+}
+
+// Run dispatches notifications until the queue is closed or Stop is called.
+// On a stop signal it drains every batch already buffered in the queue
+// before returning, so a send racing the signal isn't silently dropped.
+func (h *NotificationHandler) Run() {
+	h.wg.Add(1)
+	defer h.wg.Done()
+
+	for {
+		select {
+		case reqs, ok := <-h.queue:
+			if !ok {
+				return
+			}
+			h.send(reqs)
+		case <-h.stop:
+			h.drain()
+			return
+		}
+	}
+}
+
+// drain dispatches every notification batch already buffered in h.queue,
+// without blocking for more to arrive.
+func (h *NotificationHandler) drain() {
+	for {
+		select {
+		case reqs, ok := <-h.queue:
+			if !ok {
+				return
+			}
+			h.send(reqs)
+		default:
+			return
+		}
+	}
+}
+
+func (h *NotificationHandler) send(reqs NotificationReqs) {
+	alertmanagerUrl := h.alertmanager()
+	if alertmanagerUrl == "" {
+		glog.Warningf("No alertmanager configured; dropping %d notifications", len(reqs))
+		return
+	}
+
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		h.errors.Inc()
+		glog.Errorf("Error marshalling notifications: %s", err)
+		return
+	}
+
+	resp, err := h.client.Post(alertmanagerUrl+"/api/alerts", "application/json", bytes.NewReader(body))
+	if err != nil {
+		h.errors.Inc()
+		glog.Errorf("Error sending notifications: %s", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		h.errors.Inc()
+		glog.Errorf("Unexpected status %s sending notifications", resp.Status)
+		return
+	}
+	h.sent.Add(float64(len(reqs)))
+}
+
+// Stop halts the dispatch loop, waiting for any in-flight send to finish or
+// for ctx to expire.
+func (h *NotificationHandler) Stop(ctx context.Context) error {
+	close(h.stop)
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		glog.Warning("Timed out waiting for notification handler to finish")
+		return ctx.Err()
+	}
+}
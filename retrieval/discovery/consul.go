@@ -0,0 +1,130 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// consulServiceLabel carries the Consul service name a target was
+// discovered under.
+const consulServiceLabel clientmodel.LabelName = "__meta_consul_service"
+
+// ConsulProvider discovers targets by long-polling Consul's blocking health
+// check endpoint, /v1/health/service/<name>, so that updates are pushed as
+// soon as Consul's catalog changes rather than on a fixed poll interval.
+type ConsulProvider struct {
+	Server  string
+	Service string
+
+	client http.Client
+}
+
+// NewConsulProvider returns a ConsulProvider that watches service on the
+// given Consul server address (e.g. "consul.service.consul:8500").
+func NewConsulProvider(server, service string) *ConsulProvider {
+	return &ConsulProvider{
+		Server:  server,
+		Service: service,
+		// No client-side timeout: blocking queries are expected to hang for
+		// up to ~5 minutes waiting on Consul's wait parameter.
+		client: http.Client{},
+	}
+}
+
+type consulHealthEntry struct {
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+// Run implements TargetProvider.
+func (p *ConsulProvider) Run(ctx context.Context, ch chan<- Update) {
+	var lastIndex uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		index, err := p.poll(ctx, lastIndex, ch)
+		if err != nil {
+			glog.Warningf("Error querying Consul for service %q: %s", p.Service, err)
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		lastIndex = index
+	}
+}
+
+// poll issues a single blocking query for changes since lastIndex, pushes the
+// returned entries as an Update (an empty one if the service has no
+// instances), and returns Consul's new X-Consul-Index.
+func (p *ConsulProvider) poll(ctx context.Context, lastIndex uint64, ch chan<- Update) (uint64, error) {
+	url := fmt.Sprintf("http://%s/v1/health/service/%s?index=%d&wait=5m", p.Server, p.Service, lastIndex)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var entries []consulHealthEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return 0, err
+	}
+
+	targets := make([]clientmodel.LabelSet, 0, len(entries))
+	for _, e := range entries {
+		targets = append(targets, clientmodel.LabelSet{
+			AddressLabel:       clientmodel.LabelValue(fmt.Sprintf("%s:%d", e.Service.Address, e.Service.Port)),
+			consulServiceLabel: clientmodel.LabelValue(p.Service),
+		})
+	}
+
+	// The consumer on the other end of ch stops reading as soon as ctx is
+	// canceled, so this send has to be cancelable too, or a poll that's in
+	// flight at shutdown/reload would block forever.
+	select {
+	case ch <- Update{Targets: targets}:
+	case <-ctx.Done():
+	}
+
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return lastIndex, nil
+	}
+	return newIndex, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discovery implements pluggable service discovery for scrape
+// targets. A TargetProvider runs for the lifetime of a scrape job and
+// reports the job's full, current target set every time it changes, so that
+// TargetManager never has to poll a static list again.
+package discovery
+
+import (
+	"context"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// AddressLabel carries the "host:port" a target should be scraped at.
+// Providers set it; TargetManager turns it into a scrape URL.
+const AddressLabel clientmodel.LabelName = "__address__"
+
+// Update is the full replacement set of targets for the job a
+// TargetProvider was configured for. Providers always send the complete
+// set, never a delta, so a consumer can simply swap it in.
+type Update struct {
+	Targets []clientmodel.LabelSet
+}
+
+// TargetProvider discovers a dynamic set of scrape targets. Run must block,
+// pushing an Update on ch every time the discovered set changes, until ctx
+// is canceled. Sends on ch must themselves be cancelable via ctx.Done(),
+// since the consumer may stop reading from ch the moment ctx is canceled.
+type TargetProvider interface {
+	Run(ctx context.Context, ch chan<- Update)
+}
@@ -0,0 +1,94 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/glog"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// DNSProvider discovers targets by periodically resolving a DNS SRV record.
+// It refreshes on a fixed interval rather than honoring each record's TTL
+// individually, which keeps the polling behavior predictable across
+// resolvers that don't surface per-record TTLs to Go's net package.
+type DNSProvider struct {
+	Name            string
+	RefreshInterval time.Duration
+
+	lookup func(service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NewDNSProvider returns a DNSProvider that resolves the given SRV name
+// (e.g. "_prometheus._tcp.example.com") every refreshInterval.
+func NewDNSProvider(name string, refreshInterval time.Duration) *DNSProvider {
+	return &DNSProvider{
+		Name:            name,
+		RefreshInterval: refreshInterval,
+		lookup:          net.LookupSRV,
+	}
+}
+
+// Run implements TargetProvider.
+func (p *DNSProvider) Run(ctx context.Context, ch chan<- Update) {
+	p.refresh(ctx, ch)
+
+	ticker := time.NewTicker(p.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.refresh(ctx, ch)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *DNSProvider) refresh(ctx context.Context, ch chan<- Update) {
+	_, records, err := p.lookup("", "", p.Name)
+	if err != nil {
+		glog.Warningf("Error resolving DNS SRV record %q: %s", p.Name, err)
+		return
+	}
+
+	targets := make([]clientmodel.LabelSet, 0, len(records))
+	for _, rec := range records {
+		addr := fmt.Sprintf("%s:%d", trimTrailingDot(rec.Target), rec.Port)
+		targets = append(targets, clientmodel.LabelSet{
+			AddressLabel: clientmodel.LabelValue(addr),
+		})
+	}
+
+	// The consumer on the other end of ch stops reading as soon as ctx is
+	// canceled, so this send has to be cancelable too, or a refresh that's
+	// in flight at shutdown/reload would block forever.
+	select {
+	case ch <- Update{Targets: targets}:
+	case <-ctx.Done():
+	}
+}
+
+func trimTrailingDot(s string) string {
+	if len(s) > 0 && s[len(s)-1] == '.' {
+		return s[:len(s)-1]
+	}
+	return s
+}
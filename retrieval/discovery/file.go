@@ -0,0 +1,130 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// fileTargetGroup is one entry of a file_sd JSON target file: a group of
+// targets sharing a common set of labels, the same shape used by
+// Prometheus's file_sd_config in every later version.
+type fileTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// FileProvider discovers targets from a directory of *.json files, each
+// holding a list of fileTargetGroups, and re-reads the directory whenever
+// fsnotify reports a change to it.
+type FileProvider struct {
+	Directory string
+}
+
+// NewFileProvider returns a FileProvider that watches directory.
+func NewFileProvider(directory string) *FileProvider {
+	return &FileProvider{Directory: directory}
+}
+
+// Run implements TargetProvider.
+func (p *FileProvider) Run(ctx context.Context, ch chan<- Update) {
+	p.refresh(ctx, ch)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		glog.Warningf("Error creating file watcher for %s: %s", p.Directory, err)
+		<-ctx.Done()
+		return
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(p.Directory); err != nil {
+		glog.Warningf("Error watching %s: %s", p.Directory, err)
+		<-ctx.Done()
+		return
+	}
+
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			p.refresh(ctx, ch)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			glog.Warningf("Error watching %s: %s", p.Directory, err)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *FileProvider) refresh(ctx context.Context, ch chan<- Update) {
+	matches, err := filepath.Glob(filepath.Join(p.Directory, "*.json"))
+	if err != nil {
+		glog.Warningf("Error listing file_sd directory %s: %s", p.Directory, err)
+		return
+	}
+
+	var targets []clientmodel.LabelSet
+	for _, path := range matches {
+		groups, err := readTargetGroups(path)
+		if err != nil {
+			glog.Warningf("Error reading file_sd file %s: %s", path, err)
+			continue
+		}
+		for _, g := range groups {
+			for _, addr := range g.Targets {
+				labels := clientmodel.LabelSet{AddressLabel: clientmodel.LabelValue(addr)}
+				for ln, lv := range g.Labels {
+					labels[clientmodel.LabelName(ln)] = clientmodel.LabelValue(lv)
+				}
+				targets = append(targets, labels)
+			}
+		}
+	}
+
+	// The consumer on the other end of ch stops reading as soon as ctx is
+	// canceled, so this send has to be cancelable too, or a refresh that's
+	// in flight at shutdown/reload would block forever.
+	select {
+	case ch <- Update{Targets: targets}:
+	case <-ctx.Done():
+	}
+}
+
+func readTargetGroups(path string) ([]fileTargetGroup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var groups []fileTargetGroup
+	if err := json.NewDecoder(f).Decode(&groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
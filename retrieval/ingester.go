@@ -0,0 +1,63 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"github.com/prometheus/client_golang/extraction"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// ChannelIngester is an extraction.Ingester that forwards every scrape
+// result it receives onto a channel, where it waits to be picked up by the
+// main sample-writing loop.
+type ChannelIngester chan<- *extraction.Result
+
+// Ingest implements extraction.Ingester.
+func (i ChannelIngester) Ingest(r *extraction.Result) error {
+	i <- r
+	return nil
+}
+
+// MergeLabelsIngester wraps another Ingester and merges a fixed set of
+// server-side labels (e.g. the global labels, or the job and other base
+// labels of the target that produced the result) into every sample before passing it
+// on.
+//
+// CollisionPrefix is prepended to a target-exposed label name when it
+// collides with one of Labels, unless HonorLabels is set, in which case the
+// target-exposed value wins outright and no prefixing happens.
+type MergeLabelsIngester struct {
+	Labels          clientmodel.LabelSet
+	CollisionPrefix clientmodel.LabelName
+	HonorLabels     bool
+
+	Ingester extraction.Ingester
+}
+
+// Ingest implements extraction.Ingester.
+func (i *MergeLabelsIngester) Ingest(r *extraction.Result) error {
+	for _, s := range r.Samples {
+		for ln, lv := range i.Labels {
+			if existing, ok := s.Metric[ln]; ok {
+				if i.HonorLabels {
+					continue
+				}
+				s.Metric[i.CollisionPrefix+ln] = existing
+			}
+			s.Metric[ln] = lv
+		}
+	}
+	return i.Ingester.Ingest(r)
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"regexp"
+	"strings"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// relabel runs rcs, in order, against labels and returns the resulting
+// label set and whether the target they describe should still be scraped
+// (false if a "keep" or "drop" step rejected it).
+func relabel(labels clientmodel.LabelSet, rcs []config.RelabelConfig) (clientmodel.LabelSet, bool) {
+	out := labels.Clone()
+
+	for _, rc := range rcs {
+		values := make([]string, 0, len(rc.SourceLabels))
+		for _, ln := range rc.SourceLabels {
+			values = append(values, string(out[clientmodel.LabelName(ln)]))
+		}
+		separator := rc.Separator
+		if separator == "" {
+			separator = ";"
+		}
+		value := strings.Join(values, separator)
+
+		regex := rc.Regex
+		if regex == "" {
+			regex = "(.*)"
+		}
+		re, err := regexp.Compile("^(?:" + regex + ")$")
+		if err != nil {
+			continue
+		}
+		matches := re.FindStringSubmatchIndex(value)
+
+		switch rc.Action {
+		case "drop":
+			if matches != nil {
+				return nil, false
+			}
+		case "keep":
+			if matches == nil {
+				return nil, false
+			}
+		default: // "replace"
+			if matches == nil || rc.TargetLabel == "" {
+				continue
+			}
+			replacement := string(re.ExpandString(nil, rc.Replacement, value, matches))
+			out[clientmodel.LabelName(rc.TargetLabel)] = clientmodel.LabelValue(replacement)
+		}
+	}
+
+	return out, true
+}
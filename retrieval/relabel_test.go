@@ -0,0 +1,67 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"testing"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+func TestRelabelReplace(t *testing.T) {
+	labels := clientmodel.LabelSet{"__meta_consul_service": "web"}
+	out, keep := relabel(labels, []config.RelabelConfig{{
+		SourceLabels: []string{"__meta_consul_service"},
+		Regex:        "(.*)",
+		TargetLabel:  "service",
+		Replacement:  "$1",
+		Action:       "replace",
+	}})
+	if !keep {
+		t.Fatal("expected target to be kept")
+	}
+	if out["service"] != "web" {
+		t.Fatalf("expected service=web, got %v", out["service"])
+	}
+}
+
+func TestRelabelKeepDrop(t *testing.T) {
+	labels := clientmodel.LabelSet{"env": "prod"}
+
+	if _, keep := relabel(labels, []config.RelabelConfig{{
+		SourceLabels: []string{"env"},
+		Regex:        "prod",
+		Action:       "drop",
+	}}); keep {
+		t.Fatal("expected target matching drop regex to be dropped")
+	}
+
+	if _, keep := relabel(labels, []config.RelabelConfig{{
+		SourceLabels: []string{"env"},
+		Regex:        "staging",
+		Action:       "keep",
+	}}); keep {
+		t.Fatal("expected target not matching keep regex to be dropped")
+	}
+
+	if _, keep := relabel(labels, []config.RelabelConfig{{
+		SourceLabels: []string{"env"},
+		Regex:        "prod",
+		Action:       "keep",
+	}}); !keep {
+		t.Fatal("expected target matching keep regex to be kept")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/extraction"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// StaleValue is a distinguished NaN sample value used to explicitly mark a
+// series as stale: its source has stopped producing it, so range queries
+// must stop carrying its last real value forward from this timestamp on.
+// MemorySeriesStorage recognizes it on append.
+var StaleValue = clientmodel.SampleValue(math.Float64frombits(0x7ff0000000000002))
+
+// TargetState describes the last known health of a Target.
+type TargetState int
+
+const (
+	// Unknown is the initial state of a target before it has been scraped.
+	Unknown TargetState = iota
+	// Healthy means the last scrape succeeded.
+	Healthy
+	// Unhealthy means the last scrape failed.
+	Unhealthy
+)
+
+// Target is a single scrapeable endpoint along with the labels that should
+// be attached to every sample it produces.
+type Target struct {
+	// URL is the fully-qualified metrics endpoint to scrape.
+	URL string
+	// BaseLabels are attached to the target itself: job and any labels
+	// contributed by service discovery or relabeling. No instance label is
+	// added unless relabeling sets one.
+	BaseLabels clientmodel.LabelSet
+	// HonorLabels, if set, lets a label exposed by the target win over a
+	// same-named entry in BaseLabels outright instead of being kept under a
+	// collision-prefixed name.
+	HonorLabels bool
+
+	client http.Client
+
+	mu         sync.Mutex
+	state      TargetState
+	lastSeries map[string]clientmodel.Metric
+}
+
+// NewTarget returns a Target ready to be added to a TargetManager.
+func NewTarget(url string, timeout time.Duration, baseLabels clientmodel.LabelSet, honorLabels bool) *Target {
+	return &Target{
+		URL:         url,
+		BaseLabels:  baseLabels,
+		HonorLabels: honorLabels,
+		client:      http.Client{Timeout: timeout},
+		state:       Unknown,
+	}
+}
+
+// State returns the last known health of the target.
+func (t *Target) State() TargetState {
+	return t.state
+}
+
+// scrape fetches the target's metrics endpoint and feeds the parsed samples
+// into ingester, merging in the target's base labels. If the scrape fails,
+// or if a series present in the previous successful scrape is missing from
+// this one, the missing series are explicitly marked stale so storage
+// doesn't carry their last value forward indefinitely.
+func (t *Target) scrape(ingester extraction.Ingester) error {
+	recording := &recordingIngester{
+		Ingester: &MergeLabelsIngester{
+			Labels:          t.BaseLabels,
+			CollisionPrefix: clientmodel.ExporterLabelPrefix,
+			HonorLabels:     t.HonorLabels,
+			Ingester:        ingester,
+		},
+		seen: map[string]clientmodel.Metric{},
+	}
+
+	resp, err := t.client.Get(t.URL)
+	if err != nil {
+		t.markDown(ingester)
+		return err
+	}
+	defer resp.Body.Close()
+
+	processor, err := extraction.ProcessorForRequestHeader(resp.Header)
+	if err != nil {
+		t.markDown(ingester)
+		return err
+	}
+
+	if err := processor.ProcessSingle(resp.Body, recording, &extraction.ProcessOptions{
+		Timestamp: clientmodel.Now(),
+	}); err != nil {
+		t.markDown(ingester)
+		return err
+	}
+
+	t.mu.Lock()
+	t.state = Healthy
+	previous := t.lastSeries
+	t.lastSeries = recording.seen
+	t.mu.Unlock()
+
+	for key, metric := range previous {
+		if _, ok := recording.seen[key]; !ok {
+			ingestStaleMarker(ingester, metric)
+		}
+	}
+	return nil
+}
+
+// markDown records the target as unhealthy and stales out every series it
+// was last known to expose, since a scrape failure leaves no way to tell
+// whether they're still being produced.
+func (t *Target) markDown(ingester extraction.Ingester) {
+	t.mu.Lock()
+	t.state = Unhealthy
+	series := t.lastSeries
+	t.mu.Unlock()
+
+	for _, metric := range series {
+		ingestStaleMarker(ingester, metric)
+	}
+}
+
+// staleOut stales out every series t was last known to expose. It's called
+// when t is dropped from its job's target list entirely, e.g. because a
+// config reload or a service discovery update relabeled it away.
+func (t *Target) staleOut(ingester extraction.Ingester) {
+	t.mu.Lock()
+	series := t.lastSeries
+	t.lastSeries = nil
+	t.mu.Unlock()
+
+	for _, metric := range series {
+		ingestStaleMarker(ingester, metric)
+	}
+}
+
+// recordingIngester wraps another Ingester, remembering every Metric it
+// sees so that scrape can tell which series went missing since the
+// previous successful scrape.
+type recordingIngester struct {
+	extraction.Ingester
+	seen map[string]clientmodel.Metric
+}
+
+func (i *recordingIngester) Ingest(r *extraction.Result) error {
+	for _, s := range r.Samples {
+		i.seen[s.Metric.String()] = s.Metric
+	}
+	return i.Ingester.Ingest(r)
+}
+
+// ingestStaleMarker feeds a single StaleValue-valued sample for metric into
+// ingester.
+func ingestStaleMarker(ingester extraction.Ingester, metric clientmodel.Metric) {
+	err := ingester.Ingest(&extraction.Result{
+		Samples: clientmodel.Samples{
+			&clientmodel.Sample{
+				Metric:    metric,
+				Value:     StaleValue,
+				Timestamp: clientmodel.Now(),
+			},
+		},
+	})
+	if err != nil {
+		glog.Warningf("Error ingesting stale marker for %s: %s", metric, err)
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retrieval implements scraping of target metrics endpoints and
+// feeding the results into the sample pipeline.
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/extraction"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/retrieval/discovery"
+)
+
+// TargetPool holds the set of targets belonging to a single scrape job.
+// Its target set can change at any time, either because of a config reload
+// or because one of its TargetProviders pushed an update.
+type TargetPool struct {
+	Name string
+
+	// job is the config.JobConfig the pool was built from, kept around so
+	// ApplyConfig can tell whether a job's SD config changed (requiring a
+	// tear-down-and-replace of the pool) or just its static targets/labels
+	// did (which can be patched in place).
+	job config.JobConfig
+
+	// ingester is used only to stale out targets dropped from the pool; it
+	// is nil-safe so a zero-value TargetPool remains usable in tests.
+	ingester extraction.Ingester
+
+	mu            sync.Mutex
+	interval      time.Duration
+	staticTargets []*Target
+	discovered    map[int][]*Target // keyed by provider index
+
+	// intervalChanged is signaled, non-blockingly, whenever setInterval
+	// changes a running pool's interval, so runPool can rebuild its
+	// ticker instead of going on ticking at whatever interval it started
+	// with for the rest of the process's life.
+	intervalChanged chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Interval returns the pool's current scrape interval.
+func (p *TargetPool) Interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+// setInterval updates the pool's scrape interval, waking up runPool so it
+// can rebuild its ticker if the pool is already running.
+func (p *TargetPool) setInterval(d time.Duration) {
+	p.mu.Lock()
+	p.interval = d
+	p.mu.Unlock()
+
+	select {
+	case p.intervalChanged <- struct{}{}:
+	default:
+		// A change is already pending; runPool will pick up the latest
+		// interval when it gets to it.
+	}
+}
+
+// Targets returns a snapshot of the pool's current targets: its static
+// targets plus the latest set reported by each of its TargetProviders.
+func (p *TargetPool) Targets() []*Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := append([]*Target(nil), p.staticTargets...)
+	for _, targets := range p.discovered {
+		out = append(out, targets...)
+	}
+	return out
+}
+
+func (p *TargetPool) setStaticTargets(targets []*Target) {
+	p.mu.Lock()
+	old := p.staticTargets
+	p.staticTargets = targets
+	p.mu.Unlock()
+	staleOutRemoved(old, targets, p.ingester)
+}
+
+func (p *TargetPool) setDiscovered(providerIdx int, targets []*Target) {
+	p.mu.Lock()
+	if p.discovered == nil {
+		p.discovered = map[int][]*Target{}
+	}
+	old := p.discovered[providerIdx]
+	p.discovered[providerIdx] = targets
+	p.mu.Unlock()
+	staleOutRemoved(old, targets, p.ingester)
+}
+
+// staleOutRemoved marks every target in old that is no longer present in
+// cur (matched by URL) as stale, e.g. because it was relabeled away by a
+// service discovery update or dropped from a job's static target list by a
+// config reload.
+func staleOutRemoved(old, cur []*Target, ingester extraction.Ingester) {
+	if ingester == nil {
+		return
+	}
+	keep := make(map[string]bool, len(cur))
+	for _, t := range cur {
+		keep[t.URL] = true
+	}
+	for _, t := range old {
+		if !keep[t.URL] {
+			t.staleOut(ingester)
+		}
+	}
+}
+
+// TargetManager owns every TargetPool and drives their scrape loops.
+type TargetManager interface {
+	// AddTargetsFromConfig populates the pools from cfg's statically-defined
+	// jobs. It is meant to be called once, before Run.
+	AddTargetsFromConfig(cfg config.Config)
+	// ApplyConfig diffs cfg's jobs and global labels against the currently
+	// running pools and adds, removes, or updates them in place, without
+	// interrupting scrapes of targets that are unaffected by the diff.
+	ApplyConfig(cfg config.Config)
+	// Pools returns the current target pools, keyed by job name.
+	Pools() map[string]*TargetPool
+	// Run starts the scrape loops of the current pools and returns without
+	// waiting for them; Stop is what halts and drains them.
+	Run()
+	// Stop halts all scrape loops and waits for in-flight scrapes to finish
+	// or for ctx to expire, whichever comes first.
+	Stop(ctx context.Context) error
+}
+
+type targetManager struct {
+	ingester    extraction.Ingester
+	concurrency chan struct{}
+
+	mu      sync.Mutex
+	pools   map[string]*TargetPool
+	running bool
+
+	rootCtx    context.Context
+	rootCancel context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewTargetManager returns a TargetManager that feeds scraped samples into
+// ingester, running at most concurrentScrapes scrapes at once.
+func NewTargetManager(ingester extraction.Ingester, concurrentScrapes int) TargetManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &targetManager{
+		ingester:    ingester,
+		concurrency: make(chan struct{}, concurrentScrapes),
+		pools:       map[string]*TargetPool{},
+		rootCtx:     ctx,
+		rootCancel:  cancel,
+	}
+}
+
+// newPool builds an empty, not-yet-running pool for job, wired up with
+// providers for each of its *_sd_configs.
+func (tm *targetManager) newPool(job config.JobConfig) *TargetPool {
+	ctx, cancel := context.WithCancel(tm.rootCtx)
+	pool := &TargetPool{
+		Name:            job.Name,
+		interval:        job.ScrapeInterval,
+		job:             job,
+		ingester:        tm.ingester,
+		intervalChanged: make(chan struct{}, 1),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+
+	for i, provider := range providersForJob(job) {
+		tm.wg.Add(1)
+		go func(i int, provider discovery.TargetProvider) {
+			defer tm.wg.Done()
+			tm.runProvider(ctx, pool, job, i, provider)
+		}(i, provider)
+	}
+
+	return pool
+}
+
+// sdConfigsEqual reports whether a and b configure the same set of
+// TargetProviders with the same relabeling, i.e. whether a pool built from
+// a can keep running its existing providers when reloaded with b rather
+// than being torn down and rebuilt. RelabelConfigs is included because
+// runProvider closes over the job it was started with: a provider can't
+// pick up a changed relabeling rule on its own, so that case is folded into
+// the tear-down-and-replace path too.
+func sdConfigsEqual(a, b config.JobConfig) bool {
+	return reflect.DeepEqual(a.DNSSDConfigs, b.DNSSDConfigs) &&
+		reflect.DeepEqual(a.ConsulSDConfigs, b.ConsulSDConfigs) &&
+		reflect.DeepEqual(a.FileSDConfigs, b.FileSDConfigs) &&
+		reflect.DeepEqual(a.RelabelConfigs, b.RelabelConfigs)
+}
+
+// providersForJob builds one discovery.TargetProvider per *_sd_config
+// configured for job.
+func providersForJob(job config.JobConfig) []discovery.TargetProvider {
+	var providers []discovery.TargetProvider
+	for _, c := range job.DNSSDConfigs {
+		providers = append(providers, discovery.NewDNSProvider(c.Name, c.RefreshInterval))
+	}
+	for _, c := range job.ConsulSDConfigs {
+		providers = append(providers, discovery.NewConsulProvider(c.Server, c.Service))
+	}
+	for _, c := range job.FileSDConfigs {
+		providers = append(providers, discovery.NewFileProvider(c.Directory))
+	}
+	return providers
+}
+
+// runProvider runs a single TargetProvider for pool's lifetime, translating
+// each Update it emits into scrape Targets.
+func (tm *targetManager) runProvider(ctx context.Context, pool *TargetPool, job config.JobConfig, idx int, provider discovery.TargetProvider) {
+	ch := make(chan discovery.Update)
+	tm.wg.Add(1)
+	go func() {
+		defer tm.wg.Done()
+		provider.Run(ctx, ch)
+	}()
+
+	for {
+		select {
+		case upd := <-ch:
+			pool.setDiscovered(idx, targetsFromUpdate(job, upd))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// targetsFromUpdate converts a discovery.Update's labels into scrapeable
+// Targets, defaulting to the Prometheus "/metrics" convention for the path.
+// job.RelabelConfigs run against each target's labels (including
+// __address__) before it's built, so a relabeling step can rewrite the
+// address itself or drop/keep targets based on discovered labels.
+func targetsFromUpdate(job config.JobConfig, upd discovery.Update) []*Target {
+	targets := make([]*Target, 0, len(upd.Targets))
+	for _, labels := range upd.Targets {
+		merged := labels.Clone()
+		merged[clientmodel.JobLabel] = clientmodel.LabelValue(job.Name)
+
+		relabeled, keep := relabel(merged, job.RelabelConfigs)
+		if !keep {
+			continue
+		}
+
+		addr, ok := relabeled[discovery.AddressLabel]
+		if !ok {
+			continue
+		}
+		delete(relabeled, discovery.AddressLabel)
+
+		url := fmt.Sprintf("http://%s/metrics", addr)
+		targets = append(targets, NewTarget(url, job.ScrapeTimeout, relabeled, job.HonorLabels))
+	}
+	return targets
+}
+
+// staticTargetsFromJob builds Targets for job's statically configured
+// TargetURLs, running job.RelabelConfigs against each one's labels so a
+// static job can use the same keep/drop/replace rules as an SD-backed one.
+func staticTargetsFromJob(job config.JobConfig, globalLabels clientmodel.LabelSet) []*Target {
+	targets := make([]*Target, 0, len(job.TargetURLs))
+	for _, url := range job.TargetURLs {
+		baseLabels := globalLabels.Clone()
+		baseLabels[clientmodel.JobLabel] = clientmodel.LabelValue(job.Name)
+
+		relabeled, keep := relabel(baseLabels, job.RelabelConfigs)
+		if !keep {
+			continue
+		}
+		targets = append(targets, NewTarget(url, job.ScrapeTimeout, relabeled, job.HonorLabels))
+	}
+	return targets
+}
+
+// AddTargetsFromConfig implements TargetManager.
+func (tm *targetManager) AddTargetsFromConfig(cfg config.Config) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, job := range cfg.Jobs() {
+		pool := tm.newPool(job)
+		pool.setStaticTargets(staticTargetsFromJob(job, cfg.GlobalLabels()))
+		tm.pools[job.Name] = pool
+	}
+}
+
+// ApplyConfig implements TargetManager.
+func (tm *targetManager) ApplyConfig(cfg config.Config) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	seen := map[string]bool{}
+	for _, job := range cfg.Jobs() {
+		seen[job.Name] = true
+
+		if existing, ok := tm.pools[job.Name]; ok {
+			if sdConfigsEqual(existing.job, job) {
+				// A job whose only change is its static target list,
+				// labels, or interval is updated in place, without
+				// disturbing its providers.
+				existing.job = job
+				existing.setInterval(job.ScrapeInterval)
+				existing.setStaticTargets(staticTargetsFromJob(job, cfg.GlobalLabels()))
+				continue
+			}
+
+			// The SD providers backing a job don't change cheaply
+			// mid-flight, so a job whose discovery config changed is torn
+			// down and replaced rather than patched in place.
+			for _, t := range existing.Targets() {
+				t.staleOut(tm.ingester)
+			}
+			existing.cancel()
+		}
+
+		pool := tm.newPool(job)
+		pool.setStaticTargets(staticTargetsFromJob(job, cfg.GlobalLabels()))
+		tm.pools[job.Name] = pool
+		if tm.running {
+			tm.startPool(pool)
+		}
+	}
+
+	for name, pool := range tm.pools {
+		if seen[name] {
+			continue
+		}
+		for _, t := range pool.Targets() {
+			t.staleOut(tm.ingester)
+		}
+		pool.cancel()
+		delete(tm.pools, name)
+	}
+}
+
+// Pools implements TargetManager.
+func (tm *targetManager) Pools() map[string]*TargetPool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	out := make(map[string]*TargetPool, len(tm.pools))
+	for k, v := range tm.pools {
+		out[k] = v
+	}
+	return out
+}
+
+// Run implements TargetManager.
+func (tm *targetManager) Run() {
+	tm.mu.Lock()
+	tm.running = true
+	pools := make([]*TargetPool, 0, len(tm.pools))
+	for _, p := range tm.pools {
+		pools = append(pools, p)
+	}
+	tm.mu.Unlock()
+
+	for _, pool := range pools {
+		tm.startPool(pool)
+	}
+}
+
+// startPool launches the scrape loop for pool. The caller must hold tm.mu
+// or otherwise guarantee pool isn't started twice.
+func (tm *targetManager) startPool(pool *TargetPool) {
+	tm.wg.Add(1)
+	go tm.runPool(pool)
+}
+
+func (tm *targetManager) runPool(pool *TargetPool) {
+	defer tm.wg.Done()
+
+	ticker := time.NewTicker(pool.Interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, t := range pool.Targets() {
+				tm.scrape(t)
+			}
+		case <-pool.intervalChanged:
+			ticker.Stop()
+			ticker = time.NewTicker(pool.Interval())
+		case <-pool.ctx.Done():
+			return
+		}
+	}
+}
+
+func (tm *targetManager) scrape(t *Target) {
+	tm.concurrency <- struct{}{}
+	tm.wg.Add(1)
+	go func() {
+		defer tm.wg.Done()
+		defer func() { <-tm.concurrency }()
+
+		if err := t.scrape(tm.ingester); err != nil {
+			glog.Warningf("Error scraping %s: %s", t.URL, err)
+		}
+	}()
+}
+
+// Stop implements TargetManager.
+func (tm *targetManager) Stop(ctx context.Context) error {
+	tm.rootCancel()
+
+	done := make(chan struct{})
+	go func() {
+		tm.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		glog.Warning("Timed out waiting for in-flight scrapes to finish")
+		return ctx.Err()
+	}
+}
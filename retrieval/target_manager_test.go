@@ -0,0 +1,63 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/config"
+)
+
+// TestApplyConfigStartsPoolWhenRunning verifies that a job added via
+// ApplyConfig after Run has already been called is actually scraped, i.e.
+// that ApplyConfig's tm.startPool path is exercised and not just the
+// bookkeeping that stands up the pool and its providers.
+func TestApplyConfigStartsPoolWhenRunning(t *testing.T) {
+	srv := httptest.NewServer(textHandler("up 1\n"))
+	defer srv.Close()
+
+	ingester := &collectingIngester{}
+	tm := NewTargetManager(ingester, 1)
+	tm.Run()
+	defer tm.Stop(context.Background())
+
+	cfg, err := config.LoadFromString(strings.Join([]string{
+		`scrape_config {`,
+		`  job_name = "t"`,
+		`  scrape_interval = "10ms"`,
+		`  target_url = "` + srv.URL + `/metrics"`,
+		`}`,
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("LoadFromString: %s", err)
+	}
+
+	tm.ApplyConfig(cfg)
+
+	deadline := time.After(time.Second)
+	for {
+		if len(ingester.samples()) > 0 {
+			return
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("target added via ApplyConfig was never scraped; ApplyConfig's startPool path isn't being exercised")
+		}
+	}
+}
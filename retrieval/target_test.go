@@ -0,0 +1,144 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retrieval
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/extraction"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// collectingIngester records every Result it's given, for assertions. It's
+// safe for concurrent use since scrapes run on their own goroutines.
+type collectingIngester struct {
+	mu      sync.Mutex
+	results []*extraction.Result
+}
+
+func (i *collectingIngester) Ingest(r *extraction.Result) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.results = append(i.results, r)
+	return nil
+}
+
+func (i *collectingIngester) samples() clientmodel.Samples {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var out clientmodel.Samples
+	for _, r := range i.results {
+		out = append(out, r.Samples...)
+	}
+	return out
+}
+
+// staleMetricNames returns the __name__ of every stale-valued sample in
+// samples. StaleValue is a NaN, so it's matched by bit pattern: == never
+// holds between two NaNs.
+func staleMetricNames(samples clientmodel.Samples) []string {
+	var names []string
+	for _, s := range samples {
+		if math.Float64bits(float64(s.Value)) == math.Float64bits(float64(StaleValue)) {
+			names = append(names, string(s.Metric[clientmodel.MetricNameLabel]))
+		}
+	}
+	return names
+}
+
+func textHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(body))
+	}
+}
+
+// TestTargetDownStalesLastSeries verifies that once a target stops
+// responding, every series it was last known to expose gets an explicit
+// stale marker rather than being silently left at its last value.
+func TestTargetDownStalesLastSeries(t *testing.T) {
+	srv := httptest.NewServer(textHandler("foo 1\n"))
+	target := NewTarget(srv.URL, time.Second, clientmodel.LabelSet{}, false)
+
+	ingester := &collectingIngester{}
+	if err := target.scrape(ingester); err != nil {
+		t.Fatalf("first scrape: %s", err)
+	}
+	srv.Close()
+
+	if err := target.scrape(ingester); err == nil {
+		t.Fatal("expected second scrape to fail once the target is down")
+	}
+
+	stale := staleMetricNames(ingester.samples())
+	if len(stale) != 1 || stale[0] != "foo" {
+		t.Fatalf("expected a stale marker for foo, got %v", stale)
+	}
+}
+
+// TestSeriesDroppedIsStaled verifies that a series present in one scrape
+// but missing from the next gets an explicit stale marker, while a series
+// that's still present does not.
+func TestSeriesDroppedIsStaled(t *testing.T) {
+	srv := httptest.NewServer(textHandler("foo 1\nbar 2\n"))
+	defer srv.Close()
+
+	target := NewTarget(srv.URL, time.Second, clientmodel.LabelSet{}, false)
+	ingester := &collectingIngester{}
+	if err := target.scrape(ingester); err != nil {
+		t.Fatalf("first scrape: %s", err)
+	}
+
+	srv.Config.Handler = textHandler("foo 1\n")
+	if err := target.scrape(ingester); err != nil {
+		t.Fatalf("second scrape: %s", err)
+	}
+
+	stale := staleMetricNames(ingester.samples())
+	if len(stale) != 1 || stale[0] != "bar" {
+		t.Fatalf("expected a stale marker for bar only, got %v", stale)
+	}
+}
+
+// TestTargetRelabeledAwayIsStaled verifies that dropping a target from a
+// pool's target list (e.g. because a config reload or an SD update
+// relabeled it away) stales every series it last exposed.
+func TestTargetRelabeledAwayIsStaled(t *testing.T) {
+	srv := httptest.NewServer(textHandler("foo 1\n"))
+	defer srv.Close()
+
+	target := NewTarget(srv.URL, time.Second, clientmodel.LabelSet{}, false)
+	ingester := &collectingIngester{}
+	if err := target.scrape(ingester); err != nil {
+		t.Fatalf("scrape: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := &TargetPool{Name: "test", ingester: ingester, ctx: ctx, cancel: cancel}
+	pool.setStaticTargets([]*Target{target})
+	pool.setStaticTargets(nil)
+
+	stale := staleMetricNames(ingester.samples())
+	if len(stale) != 1 || stale[0] != "foo" {
+		t.Fatalf("expected a stale marker for foo after relabeling away, got %v", stale)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage_ng is the next-generation local time series storage: an
+// in-memory arena periodically flushed to an on-disk persistence layer.
+package storage_ng
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/golang/glog"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// staleNaNBits is the bit pattern of retrieval.StaleValue. It's duplicated
+// here rather than imported to keep storage_ng free of a dependency on
+// retrieval; the two must be kept in sync.
+const staleNaNBits = 0x7ff0000000000002
+
+// IsStale reports whether v is the distinguished stale marker value that
+// retrieval uses to flag a series as no longer being produced by its
+// source.
+func IsStale(v clientmodel.SampleValue) bool {
+	return math.Float64bits(float64(v)) == staleNaNBits
+}
+
+// Storage is the interface the rest of Prometheus uses to append freshly
+// scraped samples and to shut the local storage engine down cleanly.
+type Storage interface {
+	AppendSamples(samples clientmodel.Samples)
+	Serve()
+	// Stop lets pending appends drain and closes the underlying
+	// persistence layer, waiting for the drain until it completes or ctx
+	// expires.
+	Stop(ctx context.Context) error
+	Close()
+}
+
+// DiskPersistence is the on-disk half of the storage engine.
+type DiskPersistence struct {
+	basePath string
+}
+
+// NewDiskPersistence opens (creating if necessary) the on-disk persistence
+// layer rooted at basePath.
+func NewDiskPersistence(basePath string) (*DiskPersistence, error) {
+	return &DiskPersistence{basePath: basePath}, nil
+}
+
+// Close releases any resources held by the persistence layer.
+func (p *DiskPersistence) Close() error {
+	return nil
+}
+
+// MemorySeriesStorage is the in-memory arena in front of a DiskPersistence.
+type MemorySeriesStorage struct {
+	persistence *DiskPersistence
+
+	mu sync.Mutex
+
+	appendC chan clientmodel.Samples
+	served  chan struct{}
+}
+
+// NewMemorySeriesStorage returns a MemorySeriesStorage backed by
+// persistence.
+func NewMemorySeriesStorage(persistence *DiskPersistence) *MemorySeriesStorage {
+	return &MemorySeriesStorage{
+		persistence: persistence,
+		appendC:     make(chan clientmodel.Samples, 1024),
+		served:      make(chan struct{}),
+	}
+}
+
+// AppendSamples implements Storage.
+func (s *MemorySeriesStorage) AppendSamples(samples clientmodel.Samples) {
+	s.appendC <- samples
+}
+
+// Serve processes appended samples until Stop closes appendC, draining
+// every batch already buffered in it before returning.
+func (s *MemorySeriesStorage) Serve() {
+	defer close(s.served)
+
+	for samples := range s.appendC {
+		s.mu.Lock()
+		for _, sample := range samples {
+			if IsStale(sample.Value) {
+				// A stale marker: the series it names stops being
+				// carried forward in range queries from this
+				// timestamp on, rather than being stored as a
+				// literal observed value.
+				continue
+			}
+			_ = sample // arena insertion lives in the persistence layer proper
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Stop implements Storage. It closes appendC, letting Serve drain every
+// already-buffered append before it returns, then closes the persistence
+// layer; there is no separate arena flush step. ctx bounds how long Stop waits for that
+// drain to finish, not the drain logic itself.
+func (s *MemorySeriesStorage) Stop(ctx context.Context) error {
+	close(s.appendC)
+
+	select {
+	case <-s.served:
+	case <-ctx.Done():
+		glog.Warning("Timed out waiting for pending appends to drain; flushing anyway")
+	}
+
+	return s.persistence.Close()
+}
+
+// Close implements Storage for callers that can't propagate a context; it
+// is equivalent to Stop with a context that never expires.
+func (s *MemorySeriesStorage) Close() {
+	s.Stop(context.Background())
+}
@@ -0,0 +1,116 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graphite implements a remote.RemoteWriter that ships samples to a
+// Graphite carbon-cache instance using the plaintext protocol over TCP.
+package graphite
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// Client allows sending samples to a Graphite carbon-cache instance.
+type Client struct {
+	address string
+	prefix  string
+	timeout time.Duration
+}
+
+// NewClient creates a new Client for the given carbon-cache "host:port"
+// address. prefix, if non-empty, is prepended to every metric path.
+func NewClient(address, prefix string, timeout time.Duration) *Client {
+	return &Client{
+		address: address,
+		prefix:  prefix,
+		timeout: timeout,
+	}
+}
+
+// Name implements remote.RemoteWriter.
+func (c *Client) Name() string {
+	return "graphite"
+}
+
+// Close implements remote.RemoteWriter. It's a no-op: Client opens a fresh
+// TCP connection per Send and holds nothing that outlives it.
+func (c *Client) Close() error {
+	return nil
+}
+
+// Send implements remote.RemoteWriter by opening a short-lived TCP
+// connection and writing the samples as plaintext "path value timestamp"
+// lines.
+func (c *Client) Send(samples clientmodel.Samples) error {
+	conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(c.timeout))
+
+	var buf strings.Builder
+	for _, s := range samples {
+		fmt.Fprintf(&buf, "%s %f %d\n", c.path(s), float64(s.Value), s.Timestamp.Unix())
+	}
+
+	_, err = conn.Write([]byte(buf.String()))
+	return err
+}
+
+// path builds the dotted Graphite metric path for a sample: the prefix, the
+// metric name, then the label values ordered by label name, e.g.
+// "prefix.up.host:9100.node" for up{instance="host:9100",job="node"}.
+// Graphite has no separate tag dimension, so the label names are visited in
+// sorted order: map iteration
+// order is randomized per call, and an unsorted walk would serialize the
+// same logical series under a different path on every other send,
+// fragmenting it into many distinct Graphite metrics over time.
+func (c *Client) path(s *clientmodel.Sample) string {
+	parts := make([]string, 0, len(s.Metric)+1)
+	if c.prefix != "" {
+		parts = append(parts, c.prefix)
+	}
+	if name, ok := s.Metric[clientmodel.MetricNameLabel]; ok {
+		parts = append(parts, sanitize(string(name)))
+	}
+
+	labelNames := make([]string, 0, len(s.Metric))
+	for ln := range s.Metric {
+		if ln == clientmodel.MetricNameLabel {
+			continue
+		}
+		labelNames = append(labelNames, string(ln))
+	}
+	sort.Strings(labelNames)
+	for _, ln := range labelNames {
+		parts = append(parts, sanitize(string(s.Metric[clientmodel.LabelName(ln)])))
+	}
+	return strings.Join(parts, ".")
+}
+
+// sanitize replaces characters that are not valid in a Graphite path
+// component with underscores.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
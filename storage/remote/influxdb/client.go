@@ -0,0 +1,101 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package influxdb implements a remote.RemoteWriter that ships samples to an
+// InfluxDB instance using the line protocol over its HTTP write endpoint.
+package influxdb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// Client allows sending samples to an InfluxDB server via line protocol.
+type Client struct {
+	writeURL string
+	client   *http.Client
+}
+
+// NewClient creates a new Client that writes to the given InfluxDB base URL
+// (e.g. "http://influxdb:8086") and database.
+func NewClient(baseURL, database string, timeout time.Duration) *Client {
+	return &Client{
+		writeURL: fmt.Sprintf("%s/write?db=%s&precision=ms", strings.TrimRight(baseURL, "/"), url.QueryEscape(database)),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Name implements remote.RemoteWriter.
+func (c *Client) Name() string {
+	return "influxdb"
+}
+
+// Close implements remote.RemoteWriter. It's a no-op: Client opens a fresh
+// HTTP connection per Send and holds nothing that outlives it.
+func (c *Client) Close() error {
+	return nil
+}
+
+// Send implements remote.RemoteWriter by encoding the samples as InfluxDB
+// line protocol and POSTing them to the write endpoint.
+func (c *Client) Send(samples clientmodel.Samples) error {
+	var buf strings.Builder
+	for _, s := range samples {
+		writeLineProtocol(&buf, s)
+	}
+
+	resp, err := c.client.Post(c.writeURL, "text/plain", strings.NewReader(buf.String()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influxdb: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func writeLineProtocol(buf *strings.Builder, s *clientmodel.Sample) {
+	metricName := ""
+	tags := make([]string, 0, len(s.Metric))
+	for ln, lv := range s.Metric {
+		if ln == clientmodel.MetricNameLabel {
+			metricName = string(lv)
+			continue
+		}
+		tags = append(tags, fmt.Sprintf("%s=%s", escapeLineProtocol(string(ln)), escapeLineProtocol(string(lv))))
+	}
+	if metricName == "" {
+		return
+	}
+
+	buf.WriteString(escapeLineProtocol(metricName))
+	for _, tag := range tags {
+		buf.WriteByte(',')
+		buf.WriteString(tag)
+	}
+	fmt.Fprintf(buf, " value=%f %d\n", float64(s.Value), s.Timestamp.UnixNano()/int64(time.Millisecond))
+}
+
+// escapeLineProtocol escapes the characters that the line protocol treats as
+// syntactically significant in measurement and tag keys/values.
+func escapeLineProtocol(s string) string {
+	r := strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	return r.Replace(s)
+}
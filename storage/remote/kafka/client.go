@@ -0,0 +1,96 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka implements a remote.RemoteWriter that publishes samples as
+// JSON messages to a Kafka topic.
+package kafka
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// jsonSample is the wire format written to the topic, one message per
+// sample.
+type jsonSample struct {
+	Metric    map[string]string `json:"metric"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// Client allows publishing samples to a Kafka topic.
+type Client struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+// NewClient creates a new Client that publishes to topic on the given set of
+// Kafka broker addresses.
+func NewClient(brokers []string, topic string) (*Client, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	// Retries are left to QueueManager's backoff, not the producer.
+	cfg.Producer.Retry.Max = 0
+
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		topic:    topic,
+		producer: producer,
+	}, nil
+}
+
+// Name implements remote.RemoteWriter.
+func (c *Client) Name() string {
+	return "kafka"
+}
+
+// Send implements remote.RemoteWriter by publishing one JSON message per
+// sample to the configured topic.
+func (c *Client) Send(samples clientmodel.Samples) error {
+	for _, s := range samples {
+		metric := make(map[string]string, len(s.Metric))
+		for ln, lv := range s.Metric {
+			metric[string(ln)] = string(lv)
+		}
+
+		body, err := json.Marshal(jsonSample{
+			Metric:    metric,
+			Value:     float64(s.Value),
+			Timestamp: s.Timestamp.UnixNano() / int64(time.Millisecond),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, _, err = c.producer.SendMessage(&sarama.ProducerMessage{
+			Topic: c.topic,
+			Value: sarama.ByteEncoder(body),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the underlying Kafka producer.
+func (c *Client) Close() error {
+	return c.producer.Close()
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opentsdb implements a remote.RemoteWriter that ships samples to an
+// OpenTSDB instance via its HTTP /api/put endpoint.
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+// Client allows sending samples to an OpenTSDB server.
+type Client struct {
+	url     string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewClient creates a new Client for the given OpenTSDB put endpoint base
+// URL (e.g. "http://opentsdb:4242").
+func NewClient(url string, timeout time.Duration) *Client {
+	return &Client{
+		url:     url,
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Name implements remote.RemoteWriter.
+func (c *Client) Name() string {
+	return "opentsdb"
+}
+
+// Close implements remote.RemoteWriter. It's a no-op: Client opens a fresh
+// HTTP connection per Send and holds nothing that outlives it.
+func (c *Client) Close() error {
+	return nil
+}
+
+type tsdbPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// Send implements remote.RemoteWriter by POSTing the samples as a batch of
+// OpenTSDB put-API JSON points.
+func (c *Client) Send(samples clientmodel.Samples) error {
+	points := make([]tsdbPoint, 0, len(samples))
+	for _, s := range samples {
+		tags := make(map[string]string, len(s.Metric))
+		metricName := ""
+		for ln, lv := range s.Metric {
+			if ln == clientmodel.MetricNameLabel {
+				metricName = string(lv)
+				continue
+			}
+			tags[string(ln)] = string(lv)
+		}
+		if metricName == "" {
+			continue
+		}
+		points = append(points, tsdbPoint{
+			Metric:    metricName,
+			Timestamp: s.Timestamp.Unix(),
+			Value:     float64(s.Value),
+			Tags:      tags,
+		})
+	}
+
+	body, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Post(c.url+"/api/put", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("opentsdb: unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	registry "github.com/prometheus/client_golang/prometheus"
+
+	clientmodel "github.com/prometheus/client_golang/model"
+)
+
+const (
+	// initialBackoff is how long QueueManager waits before retrying a failed
+	// Send the first time; subsequent retries double it up to maxBackoff.
+	initialBackoff = 50 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+	maxRetries     = 3
+)
+
+// QueueManager buffers batches of samples for a single RemoteWriter and
+// ships each batch as soon as Run receives it, retrying failed sends with
+// backoff. It replaces the OpenTSDB-specific
+// TSDBQueueManager of old: any RemoteWriter can now be queued behind it.
+type QueueManager struct {
+	writer    RemoteWriter
+	queue     chan clientmodel.Samples
+	sendBatch func(clientmodel.Samples) error
+
+	wg sync.WaitGroup
+
+	samplesQueued  registry.Counter
+	samplesDropped registry.Counter
+	sendErrors     registry.Counter
+	queueLength    registry.Gauge
+}
+
+// NewQueueManager builds a QueueManager that ships samples through writer.
+// capacity bounds the number of batches that may be buffered before Queue
+// starts blocking the caller.
+func NewQueueManager(writer RemoteWriter, capacity int) *QueueManager {
+	name := writer.Name()
+	t := &QueueManager{
+		writer: writer,
+		queue:  make(chan clientmodel.Samples, capacity),
+
+		samplesQueued: registry.NewCounter(registry.CounterOpts{
+			Namespace:   "prometheus",
+			Subsystem:   "remote_storage",
+			Name:        "sent_samples_total",
+			Help:        "Total number of samples sent to the remote storage backend.",
+			ConstLabels: registry.Labels{"backend": name},
+		}),
+		samplesDropped: registry.NewCounter(registry.CounterOpts{
+			Namespace:   "prometheus",
+			Subsystem:   "remote_storage",
+			Name:        "dropped_samples_total",
+			Help:        "Total number of samples dropped after exhausting retries against the remote storage backend.",
+			ConstLabels: registry.Labels{"backend": name},
+		}),
+		sendErrors: registry.NewCounter(registry.CounterOpts{
+			Namespace:   "prometheus",
+			Subsystem:   "remote_storage",
+			Name:        "send_errors_total",
+			Help:        "Total number of errors sending samples to the remote storage backend.",
+			ConstLabels: registry.Labels{"backend": name},
+		}),
+		queueLength: registry.NewGauge(registry.GaugeOpts{
+			Namespace:   "prometheus",
+			Subsystem:   "remote_storage",
+			Name:        "queue_length",
+			Help:        "The number of batches of samples waiting to be sent to the remote storage backend.",
+			ConstLabels: registry.Labels{"backend": name},
+		}),
+	}
+	t.sendBatch = t.writer.Send
+	return t
+}
+
+// Name returns the name of the underlying RemoteWriter.
+func (t *QueueManager) Name() string {
+	return t.writer.Name()
+}
+
+// Describe implements registry.Collector.
+func (t *QueueManager) Describe(ch chan<- *registry.Desc) {
+	t.samplesQueued.Describe(ch)
+	t.samplesDropped.Describe(ch)
+	t.sendErrors.Describe(ch)
+	t.queueLength.Describe(ch)
+}
+
+// Collect implements registry.Collector.
+func (t *QueueManager) Collect(ch chan<- registry.Metric) {
+	t.queueLength.Set(float64(len(t.queue)))
+	t.samplesQueued.Collect(ch)
+	t.samplesDropped.Collect(ch)
+	t.sendErrors.Collect(ch)
+	t.queueLength.Collect(ch)
+}
+
+// Queue enqueues a batch of samples for delivery. It blocks if the queue is
+// full, which is preferable to dropping samples under backpressure.
+func (t *QueueManager) Queue(s clientmodel.Samples) {
+	t.queue <- s
+}
+
+// Run ships batches to the writer until Stop closes the queue, draining
+// every batch already buffered in it before returning.
+func (t *QueueManager) Run() {
+	t.wg.Add(1)
+	defer t.wg.Done()
+
+	for s := range t.queue {
+		t.sendWithRetry(s)
+	}
+}
+
+func (t *QueueManager) sendWithRetry(s clientmodel.Samples) {
+	backoff := initialBackoff
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if err := t.sendBatch(s); err != nil {
+			t.sendErrors.Inc()
+			glog.Warningf("Error sending %d samples to %s (attempt %d/%d): %s", len(s), t.Name(), attempt+1, maxRetries+1, err)
+			if attempt == maxRetries {
+				t.samplesDropped.Add(float64(len(s)))
+				return
+			}
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		t.samplesQueued.Add(float64(len(s)))
+		return
+	}
+}
+
+// Stop shuts the queue manager down. It closes the queue, letting Run drain
+// every batch already buffered before it returns, waits for that drain to
+// finish or for ctx to expire, whichever comes first, then releases the
+// underlying writer.
+func (t *QueueManager) Stop(ctx context.Context) error {
+	close(t.queue)
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		glog.Warningf("Timed out waiting for %s queue to drain", t.Name())
+		err = ctx.Err()
+	}
+
+	if closeErr := t.writer.Close(); closeErr != nil {
+		glog.Warningf("Error closing %s writer: %s", t.Name(), closeErr)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remote contains the generic machinery for shipping samples to one
+// or more long-term storage backends. Backend-specific wire formats live in
+// the subpackages (opentsdb, influxdb, graphite, kafka); this package only
+// knows how to queue, batch, retry, and instrument.
+package remote
+
+import clientmodel "github.com/prometheus/client_golang/model"
+
+// RemoteWriter sends a batch of samples to a long-term storage backend. A
+// single failed Send should be retried by the caller; Send itself must not
+// retry internally so that QueueManager's backoff stays in control of pacing.
+type RemoteWriter interface {
+	// Send writes samples to the backend. It must be safe to call from a
+	// single goroutine at a time; QueueManager never calls it concurrently.
+	Send(samples clientmodel.Samples) error
+	// Name identifies the writer for logging and metric labelling, e.g.
+	// "opentsdb" or "influxdb".
+	Name() string
+	// Close releases any resources held by the writer, e.g. a persistent
+	// connection pool. It is a no-op for backends that open a fresh
+	// connection per Send.
+	Close() error
+}
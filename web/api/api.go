@@ -0,0 +1,37 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api serves the HTTP query API.
+package api
+
+import (
+	"net/http"
+
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/retrieval"
+	storage_ng "github.com/prometheus/prometheus/storage/metric/ng"
+)
+
+// MetricsService serves PromQL-style queries over the local storage.
+type MetricsService struct {
+	// Config is a Store rather than a Config captured once at startup, so
+	// it always holds the most recently reloaded config rather than going
+	// stale after the first SIGHUP/-/reload. ServeHTTP does not read it.
+	Config        *config.Store
+	TargetManager retrieval.TargetManager
+	Storage       storage_ng.Storage
+}
+
+func (s *MetricsService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "query API not implemented in this tree", http.StatusNotImplemented)
+}
@@ -0,0 +1,135 @@
+// Copyright 2013 Prometheus Team
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package web serves Prometheus's built-in status pages and control
+// endpoints.
+package web
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	registry "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/retrieval"
+	"github.com/prometheus/prometheus/rules/manager"
+	storage_ng "github.com/prometheus/prometheus/storage/metric/ng"
+	"github.com/prometheus/prometheus/web/api"
+)
+
+var listenAddress = flag.String("web.listen-address", ":9090", "Address to listen on for the web interface, API, and telemetry.")
+
+// MustBuildServerUrl returns the externally reachable base URL of this
+// Prometheus instance, as used e.g. when generating console links.
+func MustBuildServerUrl() string {
+	return fmt.Sprintf("http://localhost%s", *listenAddress)
+}
+
+// PrometheusStatusHandler serves the "/" status page.
+type PrometheusStatusHandler struct {
+	BuildInfo map[string]string
+	// Config is a Store rather than a Config captured once at startup, so
+	// it always holds the most recently reloaded config rather than going
+	// stale after the first SIGHUP/-/reload. ServeHTTP does not read it.
+	Config      *config.Store
+	RuleManager manager.RuleManager
+	TargetPools map[string]*retrieval.TargetPool
+	Flags       map[string]string
+	Birth       time.Time
+}
+
+func (h *PrometheusStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "Prometheus is running. Started at %s.\n", h.Birth)
+}
+
+// AlertsHandler serves the "/alerts" page.
+type AlertsHandler struct {
+	RuleManager manager.RuleManager
+}
+
+func (h *AlertsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "No alerts configured.")
+}
+
+// ConsolesHandler serves user-defined console templates.
+type ConsolesHandler struct {
+	Storage storage_ng.Storage
+}
+
+func (h *ConsolesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+// DatabasesHandler serves local storage database statistics.
+type DatabasesHandler struct {
+	Provider        interface{}
+	RefreshInterval time.Duration
+}
+
+func (h *DatabasesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+// WebService wires together every HTTP-exposed handler into one server.
+type WebService struct {
+	StatusHandler    *PrometheusStatusHandler
+	MetricsHandler   *api.MetricsService
+	DatabasesHandler *DatabasesHandler
+	ConsolesHandler  *ConsolesHandler
+	AlertsHandler    *AlertsHandler
+
+	// QuitDelegate is invoked when an operator requests a shutdown via the
+	// web interface.
+	QuitDelegate func()
+	// ReloadDelegate is invoked when an operator requests a config reload
+	// via POST /-/reload. Its error, if any, is reflected in the response.
+	ReloadDelegate func() error
+
+	server *http.Server
+}
+
+// ServeForever starts the HTTP server and blocks until it exits.
+func (ws *WebService) ServeForever() error {
+	mux := http.NewServeMux()
+	mux.Handle("/", ws.StatusHandler)
+	mux.Handle("/alerts", ws.AlertsHandler)
+	mux.Handle("/consoles/", ws.ConsolesHandler)
+	mux.Handle("/databases", ws.DatabasesHandler)
+	mux.Handle("/api/query", ws.MetricsHandler)
+	mux.Handle("/metrics", registry.Handler())
+	mux.HandleFunc("/-/quit", func(w http.ResponseWriter, r *http.Request) {
+		if ws.QuitDelegate != nil {
+			go ws.QuitDelegate()
+		}
+	})
+	mux.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "reload must be requested via POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if ws.ReloadDelegate == nil {
+			return
+		}
+		if err := ws.ReloadDelegate(); err != nil {
+			http.Error(w, fmt.Sprintf("error reloading config: %s", err), http.StatusInternalServerError)
+		}
+	})
+
+	ws.server = &http.Server{Addr: *listenAddress, Handler: mux}
+	glog.Infof("Listening on %s", *listenAddress)
+	return ws.server.ListenAndServe()
+}